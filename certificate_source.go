@@ -0,0 +1,160 @@
+// Copyright 2020 John Farley. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package kvcert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+	"time"
+)
+
+// minCertificateRefreshBackoff and maxCertificateRefreshBackoff bound the exponential backoff
+// CertificateSource applies after a failed refresh attempt.
+const (
+	minCertificateRefreshBackoff = time.Second
+	maxCertificateRefreshBackoff = time.Minute
+)
+
+// CertificateSource periodically re-polls Azure Key Vault for the latest enabled version of
+// a certificate, caching it for use by a tls.Config. It eliminates the need to restart a
+// server when Key Vault rotates a certificate.
+type CertificateSource struct {
+	fetch           func(ctx context.Context) (*tls.Certificate, error)
+	refreshInterval time.Duration
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	onRotate func(old, new *tls.Certificate)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewCertificateSource fetches certName from kv and starts a background goroutine that
+// re-fetches it every refreshInterval, keeping the cached certificate current as Key Vault
+// rotates it. Cancelling ctx, or calling the returned CertificateSource's Close method, stops
+// the goroutine.
+func (kv *AzureKeyVault) NewCertificateSource(ctx context.Context, certName string, refreshInterval time.Duration) (*CertificateSource, error) {
+	return newCertificateSource(ctx, refreshInterval, func(ctx context.Context) (*tls.Certificate, error) {
+		return kv.GetCertificate(ctx, certName)
+	})
+}
+
+// newCertificateSource is the shared constructor behind NewCertificateSource, taking the
+// certificate fetch logic as a function so it can be swapped out in tests without a live
+// Key Vault.
+func newCertificateSource(ctx context.Context, refreshInterval time.Duration, fetch func(ctx context.Context) (*tls.Certificate, error)) (*CertificateSource, error) {
+	if refreshInterval <= 0 {
+		return nil, errors.New("refreshInterval must be greater than zero")
+	}
+
+	cert, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	cs := &CertificateSource{
+		fetch:           fetch,
+		refreshInterval: refreshInterval,
+		cert:            cert,
+		cancel:          cancel,
+		done:            make(chan struct{}),
+	}
+
+	go cs.refreshLoop(runCtx)
+
+	return cs, nil
+}
+
+// OnRotate registers fn to be called whenever a refresh observes a new certificate version.
+// fn is called with the previously and newly cached certificates. It is not called for
+// refreshes that find no change.
+func (cs *CertificateSource) OnRotate(fn func(old, new *tls.Certificate)) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.onRotate = fn
+}
+
+// GetCertificate returns the cached certificate. It has the signature tls.Config.GetCertificate
+// requires.
+func (cs *CertificateSource) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cs.current(), nil
+}
+
+// GetClientCertificate returns the cached certificate. It has the signature
+// tls.Config.GetClientCertificate requires.
+func (cs *CertificateSource) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return cs.current(), nil
+}
+
+// Close stops the background refresh goroutine and waits for it to exit.
+func (cs *CertificateSource) Close() {
+	cs.cancel()
+	<-cs.done
+}
+
+func (cs *CertificateSource) current() *tls.Certificate {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.cert
+}
+
+// refreshLoop re-fetches the certificate via cs.fetch every cs.refreshInterval, retrying with
+// exponential backoff on transient errors, until ctx is cancelled.
+func (cs *CertificateSource) refreshLoop(ctx context.Context) {
+	defer close(cs.done)
+
+	ticker := time.NewTicker(cs.refreshInterval)
+	defer ticker.Stop()
+
+	backoff := minCertificateRefreshBackoff
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cert, err := cs.fetch(ctx)
+			if err != nil {
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return
+				}
+
+				if backoff *= 2; backoff > maxCertificateRefreshBackoff {
+					backoff = maxCertificateRefreshBackoff
+				}
+
+				continue
+			}
+
+			backoff = minCertificateRefreshBackoff
+
+			cs.mu.Lock()
+			old := cs.cert
+			rotated := !leafEqual(old, cert)
+			cs.cert = cert
+			onRotate := cs.onRotate
+			cs.mu.Unlock()
+
+			if rotated && onRotate != nil {
+				onRotate(old, cert)
+			}
+		}
+	}
+}
+
+// leafEqual reports whether a and b carry the same leaf certificate.
+func leafEqual(a, b *tls.Certificate) bool {
+	if a == nil || b == nil || len(a.Certificate) == 0 || len(b.Certificate) == 0 {
+		return false
+	}
+	return bytes.Equal(a.Certificate[0], b.Certificate[0])
+}
@@ -0,0 +1,236 @@
+// Copyright 2020 John Farley. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package kvcert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLeafEqual(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	pfxBytes, err := os.ReadFile(multiCertPFXFixture)
+	if err != nil {
+		t.Fatalf("Error reading %s: %v\n", multiCertPFXFixture, err)
+	}
+
+	certA, err := pfxToTLSCertificate(pfxBytes, "")
+	if err != nil {
+		t.Fatalf("Error converting PFX to tls.Certificate: %v\n", err)
+	}
+
+	certB, err := pfxToTLSCertificate(pfxBytes, "")
+	if err != nil {
+		t.Fatalf("Error converting PFX to tls.Certificate: %v\n", err)
+	}
+
+	if !leafEqual(certA, certB) {
+		t.Fatal("Expected certs parsed from the same PFX to compare equal")
+	}
+
+	if leafEqual(certA, nil) {
+		t.Fatal("Expected leafEqual to return false when given a nil certificate")
+	}
+}
+
+func TestNewCertificateSourceRejectsNonPositiveInterval(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	fetch := func(ctx context.Context) (*tls.Certificate, error) {
+		return loadFixtureCert(t, multiCertPFXFixture), nil
+	}
+
+	if _, err := newCertificateSource(context.Background(), 0, fetch); err == nil {
+		t.Fatal("Expected an error for a zero refreshInterval, got nil")
+	}
+
+	if _, err := newCertificateSource(context.Background(), -time.Second, fetch); err == nil {
+		t.Fatal("Expected an error for a negative refreshInterval, got nil")
+	}
+}
+
+func TestCertificateSourceRotatesOnChange(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	certA := generateSelfSignedCert(t, "a.example.com")
+	certB := generateSelfSignedCert(t, "b.example.com")
+
+	var rotateTo atomic.Bool
+	fetch := func(ctx context.Context) (*tls.Certificate, error) {
+		if rotateTo.Load() {
+			return certB, nil
+		}
+		return certA, nil
+	}
+
+	cs, err := newCertificateSource(context.Background(), 5*time.Millisecond, fetch)
+	if err != nil {
+		t.Fatalf("Error creating certificate source: %v\n", err)
+	}
+	defer cs.Close()
+
+	if !leafEqual(cs.current(), certA) {
+		t.Fatal("Expected the initially fetched certificate to be cached")
+	}
+
+	rotated := make(chan struct{})
+	cs.OnRotate(func(old, new *tls.Certificate) {
+		if !leafEqual(old, certA) || !leafEqual(new, certB) {
+			t.Errorf("OnRotate called with unexpected certificates")
+		}
+		close(rotated)
+	})
+
+	// Only now, with OnRotate registered, tell fetch to start serving certB.
+	rotateTo.Store(true)
+
+	select {
+	case <-rotated:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for OnRotate to fire")
+	}
+
+	if !leafEqual(cs.current(), certB) {
+		t.Fatal("Expected the cached certificate to be updated after rotation")
+	}
+}
+
+func TestCertificateSourceCloseStopsRefresh(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	cert := loadFixtureCert(t, multiCertPFXFixture)
+
+	var fetched atomic.Int32
+	fetch := func(ctx context.Context) (*tls.Certificate, error) {
+		fetched.Add(1)
+		return cert, nil
+	}
+
+	cs, err := newCertificateSource(context.Background(), 5*time.Millisecond, fetch)
+	if err != nil {
+		t.Fatalf("Error creating certificate source: %v\n", err)
+	}
+
+	// Let a few refreshes happen before stopping the source.
+	time.Sleep(30 * time.Millisecond)
+	cs.Close()
+
+	countAtClose := fetched.Load()
+	time.Sleep(30 * time.Millisecond)
+
+	if fetched.Load() != countAtClose {
+		t.Fatal("Expected no further fetches after Close")
+	}
+}
+
+func TestCertificateSourceStopsOnContextCancel(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	cert := loadFixtureCert(t, multiCertPFXFixture)
+	fetch := func(ctx context.Context) (*tls.Certificate, error) {
+		return cert, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cs, err := newCertificateSource(ctx, 5*time.Millisecond, fetch)
+	if err != nil {
+		t.Fatalf("Error creating certificate source: %v\n", err)
+	}
+
+	cancel()
+
+	select {
+	case <-cs.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for refreshLoop to exit after context cancellation")
+	}
+}
+
+func TestCertificateSourceBacksOffOnFetchError(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	cert := loadFixtureCert(t, multiCertPFXFixture)
+
+	var fetched atomic.Int32
+	fetch := func(ctx context.Context) (*tls.Certificate, error) {
+		if fetched.Add(1) == 1 {
+			return cert, nil
+		}
+		return nil, errors.New("simulated transient Key Vault error")
+	}
+
+	cs, err := newCertificateSource(context.Background(), 5*time.Millisecond, fetch)
+	if err != nil {
+		t.Fatalf("Error creating certificate source: %v\n", err)
+	}
+	defer cs.Close()
+
+	// The second fetch fails and enters backoff; the cached certificate must survive.
+	time.Sleep(50 * time.Millisecond)
+
+	if !leafEqual(cs.current(), cert) {
+		t.Fatal("Expected the last good certificate to remain cached through a fetch error")
+	}
+}
+
+func loadFixtureCert(t *testing.T, fixture string) *tls.Certificate {
+	t.Helper()
+
+	pfxBytes, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("Error reading %s: %v\n", fixture, err)
+	}
+
+	cert, err := pfxToTLSCertificate(pfxBytes, "")
+	if err != nil {
+		t.Fatalf("Error converting PFX to tls.Certificate: %v\n", err)
+	}
+
+	return cert
+}
+
+// generateSelfSignedCert builds a distinct, throwaway self-signed tls.Certificate for
+// commonName, so tests can exercise rotation detection without depending on two testdata
+// fixtures happening to carry different leaf certificates.
+func generateSelfSignedCert(t *testing.T, commonName string) *tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %v\n", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		t.Fatalf("Error generating serial number: %v\n", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %v\n", err)
+	}
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
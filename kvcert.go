@@ -6,143 +6,503 @@
 // fetch a Certificate from Azure Key Vault. The certificate can then be used
 // in your Go web server to support TLS communication.
 //
-// A trivial example is below. This example uses the following environment
-// variables:
+// A trivial example is below. This example authenticates using the
+// AZURE_TENANT_ID/AZURE_CLIENT_ID/AZURE_CLIENT_SECRET environment variables,
+// but Authorize also supports AKS Workload Identity and Managed Identity -
+// see AuthOptions for details.
 //
-// KEY_VAULT_NAME: name of your Azure Key Vault
+//	package main
 //
-// KEY_VAULT_CERT_NAME: name of your certificate in Azure Key Vault
+//	import (
+//		"context"
+//		"crypto/tls"
+//		"log"
+//		"net/http"
 //
-// AZURE_TENANT_ID: azure tenant id (not visible in example, but required by azure-sdk-for-go)
+//		"github.com/jfarleyx/go-keyvault-cert"
+//	)
 //
-// AZURE_CLIENT_ID: azure client id (not visible in example, but required by azure-sdk-for-go)
+//	func main() {
+//		// Create new key vault certificate object that will be used to fetch certificate
+//		akv := kvcert.New(os.Getenv("KEY_VAULT_NAME"))
 //
-// AZURE_CLIENT_SECRET: azure client secret (not visible in example, but required by azure-sdk-for-go)
+//		// Authorize access to Azure Key Vault. With a zero-value AuthOptions, Authorize
+//		// tries, in order: AKS Workload Identity, Managed Identity, the legacy
+//		// AZURE_TENANT_ID/AZURE_CLIENT_ID/AZURE_CLIENT_SECRET environment variables, and
+//		// finally azidentity.DefaultAzureCredential.
+//		err := akv.Authorize(kvcert.AuthOptions{})
+//		if err != nil {
+//		  log.Fatalf("Error attempting to authorize azure key vault: %v", err)
+//		}
 //
-//  package main
+//		ctx := context.Background()
 //
-//  import (
-//  	"context"
-//  	"crypto/tls"
-//  	"log"
-//  	"net/http"
+//		// Fetch certificate from Azure Key Vault
+//		cert, err := akv.GetCertificate(ctx, os.Getenv("KEY_VAULT_CERT_NAME"))
+//		if err != nil {
+//		  log.Fatalf("Error attempting to fetch certificate: %v", err)
+//		}
 //
-//  	"github.com/jfarleyx/go-keyvault-cert"
-//  )
+//		// Add x509 certificate to tls configuration
+//		tlsConfig := &tls.Config{
+//		  Certificates: []tls.Certificates{cert},
+//		}
 //
-//  func main() {
-//  	// Create new key vault certificate object that will be used to fetch certificate
-//  	akv := kvcert.New(os.Getenv("KEY_VAULT_NAME"))
+//		// Add tls configuration to http server
+//		server := &http.Server{
+//		  Addr:      ":44366",
+//		  TLSConfig: tlsConfig,
+//		}
 //
-//  	// Authorize access to Azure Key Vault utilizing environment variables mentioned above.
-//  	err := akv.AuthorizeFromEnvironment()
-//  	if err != nil {
-//  	  log.Fatalf("Error attempting to authorize azure key vault: %v", err)
-//  	}
-//
-//  	ctx := context.Background()
-//
-//  	// Fetch certificate from Azure Key Vault
-//  	cert, err := akv.GetCertificate(ctx, os.Getenv("KEY_VAULT_CERT_NAME"))
-//  	if err != nil {
-//  	  log.Fatalf("Error attempting to fetch certificate: %v", err)
-//  	}
-//
-//  	// Add x509 certificate to tls configuration
-//  	tlsConfig := &tls.Config{
-//  	  Certificates: []tls.Certificates{cert},
-//  	}
-//
-//  	// Add tls configuration to http server
-//  	server := &http.Server{
-//  	  Addr:      ":44366",
-//  	  TLSConfig: tlsConfig,
-//  	}
-//
-//  	server.ListenAndServeTLS("", "")
-//  }
+//		server.ListenAndServeTLS("", "")
+//	}
 package kvcert
 
 import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/services/keyvault/auth"
-	"github.com/Azure/azure-sdk-for-go/services/keyvault/v7.0/keyvault"
-	"github.com/Azure/go-autorest/autorest/azure"
-	"golang.org/x/crypto/pkcs12"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azcertificates"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// federatedTokenRefreshInterval is how often the AKS Workload Identity federated token
+// file is re-read from disk. Kubernetes projects a fresh token on a similar cadence.
+const federatedTokenRefreshInterval = 5 * time.Minute
+
+// Cloud identifies the Azure cloud hosting a Key Vault: its AAD authority, the DNS suffix
+// used to build the vault's base URL, and the resource audience Key Vault access tokens
+// must be issued for.
+type Cloud struct {
+	// ActiveDirectoryEndpoint is the base URL of the cloud's Azure Active Directory.
+	ActiveDirectoryEndpoint string
+	// KeyVaultDNSSuffix is appended to the vault name to build the vault's base URL.
+	KeyVaultDNSSuffix string
+	// KeyVaultResource is the audience Key Vault access tokens must be issued for.
+	KeyVaultResource string
+}
+
+var (
+	// CloudPublic is Azure Public Cloud, the default used by New.
+	CloudPublic = Cloud{
+		ActiveDirectoryEndpoint: "https://login.microsoftonline.com/",
+		KeyVaultDNSSuffix:       "vault.azure.net",
+		KeyVaultResource:        "https://vault.azure.net",
+	}
+	// CloudUSGovernment is Azure Government.
+	CloudUSGovernment = Cloud{
+		ActiveDirectoryEndpoint: "https://login.microsoftonline.us/",
+		KeyVaultDNSSuffix:       "vault.usgovcloudapi.net",
+		KeyVaultResource:        "https://vault.usgovcloudapi.net",
+	}
+	// CloudChina is Azure China.
+	CloudChina = Cloud{
+		ActiveDirectoryEndpoint: "https://login.chinacloudapi.cn/",
+		KeyVaultDNSSuffix:       "vault.azure.cn",
+		KeyVaultResource:        "https://vault.azure.cn",
+	}
 )
 
+// CloudCustom builds a Cloud for sovereign or private clouds not predefined above, such as
+// Azure Stack.
+func CloudCustom(activeDirectoryEndpoint, keyVaultDNSSuffix, keyVaultResource string) Cloud {
+	return Cloud{
+		ActiveDirectoryEndpoint: activeDirectoryEndpoint,
+		KeyVaultDNSSuffix:       keyVaultDNSSuffix,
+		KeyVaultResource:        keyVaultResource,
+	}
+}
+
+// keyVaultService identifies Key Vault in a cloud.Configuration's Services map. The
+// azsecrets/azcertificates clients discover their token audience automatically from each
+// vault's authentication challenge, but registering it here lets other azidentity/azcore
+// based tooling that shares this Cloud resolve the same audience.
+const keyVaultService cloud.ServiceName = "keyVault"
+
+// aadCloudConfig translates c into the cloud.Configuration azidentity and the Key Vault
+// clients expect.
+func (c Cloud) aadCloudConfig() cloud.Configuration {
+	return cloud.Configuration{
+		ActiveDirectoryAuthorityHost: c.ActiveDirectoryEndpoint,
+		Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			keyVaultService: {
+				Audience: c.KeyVaultResource,
+				Endpoint: fmt.Sprintf("https://%s", c.KeyVaultDNSSuffix),
+			},
+		},
+	}
+}
+
 // AzureKeyVault is a Key Vault client that facilitates connecting to and communicating with an Azure Key Vault instance.
 type AzureKeyVault struct {
 	// VaultName is the name of the Azure Key Vault.
 	VaultName string
 	// authenticated is set to true when the Key Vault client is authenticated
 	authenticated bool
-	// client is the keyvault.BaseClient that facilitates communication with Azure Key Vault.
-	client keyvault.BaseClient
-	// The URL to a specific Azure Key Vault. Comprised of protocol (https), VaultName, and azure.PublicCloud.KeyVaultDNSSuffix.
+	// cloud identifies the Azure cloud hosting the vault.
+	cloud Cloud
+	// secretsClient fetches the secrets backing Key Vault certificates.
+	secretsClient *azsecrets.Client
+	// certificatesClient fetches certificate metadata and versions.
+	certificatesClient *azcertificates.Client
+	// The URL to a specific Azure Key Vault. Comprised of protocol (https), VaultName, and the vault DNS suffix.
 	vaultBaseURL string
 }
 
-// azureKeyVaultCert contains a private key and the certs associated
-// with that key that were fetched from Azure Key Vault.
-type azureKeyVaultCert struct {
-	// key represents the private key of the certificate
-	key []byte
-	// cert represents the server certificate
-	cert []byte
+// AuthOptions configures the credential chain Authorize builds to authenticate with
+// Azure Key Vault. All fields are optional; Authorize tries, in order: AKS Workload
+// Identity, Managed Identity, the legacy AZURE_TENANT_ID/AZURE_CLIENT_ID/AZURE_CLIENT_SECRET
+// environment variables, and finally azidentity.NewDefaultAzureCredential.
+type AuthOptions struct {
+	// WorkloadIdentity configures authentication via AKS Workload Identity federation.
+	// Leave the zero value to have Authorize derive it from the AZURE_FEDERATED_TOKEN_FILE,
+	// AZURE_TENANT_ID, and AZURE_CLIENT_ID environment variables injected by the AKS
+	// workload identity webhook.
+	WorkloadIdentity WorkloadIdentityOptions
+	// ManagedIdentityClientID selects a user-assigned managed identity by client ID.
+	// Leave empty to use the host's system-assigned identity, falling back to AZURE_CLIENT_ID
+	// if it is set.
+	ManagedIdentityClientID string
+}
+
+// WorkloadIdentityOptions configures the AKS Workload Identity credential Authorize adds
+// to its credential chain.
+type WorkloadIdentityOptions struct {
+	// TenantID is the Azure AD tenant to authenticate against. Defaults to AZURE_TENANT_ID.
+	TenantID string
+	// ClientID is the application (client) ID federated with the Kubernetes service account.
+	// Defaults to AZURE_CLIENT_ID.
+	ClientID string
+	// TokenFilePath is the path to the projected Kubernetes service account token. Defaults
+	// to AZURE_FEDERATED_TOKEN_FILE.
+	TokenFilePath string
 }
 
-// New creates and returns a new kvcert.AzureKeyVault struct.
+// federatedTokenCache reads the AKS Workload Identity projected service account token from
+// disk, re-reading it at most once per federatedTokenRefreshInterval rather than on every
+// AAD token exchange.
+type federatedTokenCache struct {
+	path string
+
+	mu        sync.Mutex
+	token     string
+	fetchedAt time.Time
+}
+
+// assertion satisfies the getAssertion signature required by azidentity.NewClientAssertionCredential.
+func (c *federatedTokenCache) assertion(context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Since(c.fetchedAt) < federatedTokenRefreshInterval {
+		return c.token, nil
+	}
+
+	token, err := os.ReadFile(c.path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading federated token file %q: %v", c.path, err)
+	}
+
+	c.token = strings.TrimSpace(string(token))
+	c.fetchedAt = time.Now()
+
+	return c.token, nil
+}
+
+// New creates and returns a new kvcert.AzureKeyVault struct for a vault in Azure Public Cloud.
 func New(vaultName string) *AzureKeyVault {
+	return NewWithCloud(vaultName, CloudPublic)
+}
+
+// NewWithCloud creates and returns a new kvcert.AzureKeyVault struct for a vault hosted in c,
+// e.g. CloudUSGovernment, CloudChina, or a CloudCustom sovereign/private cloud.
+func NewWithCloud(vaultName string, c Cloud) *AzureKeyVault {
 	return &AzureKeyVault{
 		VaultName:     vaultName,
 		authenticated: false,
-		client:        keyvault.New(),
-		vaultBaseURL:  fmt.Sprintf("https://%s.%s", vaultName, azure.PublicCloud.KeyVaultDNSSuffix),
+		cloud:         c,
+		vaultBaseURL:  fmt.Sprintf("https://%s.%s", vaultName, c.KeyVaultDNSSuffix),
 	}
 }
 
-// AuthorizeFromEnvironment creates a keyvault dataplane Authorizer configured from environment variables in the
-// order: 1. Client credentials 2. Client certificate 3. Username password 4. MSI. See github.com/Azure/azure-sdk-for-go/services/keyvault/auth
-// for more details.
-func (kv *AzureKeyVault) AuthorizeFromEnvironment() error {
-	if os.Getenv("AZURE_TENANT_ID") == "" {
-		return errors.New("AZURE_TENANT_ID environment variable not found")
+// Authorize authenticates with Azure Key Vault using a chained credential built from opts.
+// See AuthOptions for the order credentials are tried in.
+func (kv *AzureKeyVault) Authorize(opts AuthOptions) error {
+	clientOpts := azcore.ClientOptions{Cloud: kv.cloud.aadCloudConfig()}
+
+	var creds []azcore.TokenCredential
+
+	if wic := workloadIdentityCredential(opts.WorkloadIdentity, clientOpts); wic != nil {
+		creds = append(creds, wic)
+	}
+
+	if mic := managedIdentityCredential(opts.ManagedIdentityClientID, clientOpts); mic != nil {
+		creds = append(creds, mic)
+	}
+
+	if sec := environmentClientSecretCredential(clientOpts); sec != nil {
+		creds = append(creds, sec)
+	}
+
+	if def, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: clientOpts}); err == nil {
+		creds = append(creds, def)
+	}
+
+	if len(creds) == 0 {
+		return errors.New("No Azure credential sources available")
 	}
 
-	if os.Getenv("AZURE_CLIENT_ID") == "" {
-		return errors.New("AZURE_CLIENT_ID environment variable not found")
+	chain, err := azidentity.NewChainedTokenCredential(creds, nil)
+	if err != nil {
+		return fmt.Errorf("Error building Azure credential chain: %v", err)
 	}
 
-	if os.Getenv("AZURE_CLIENT_SECRET") == "" {
-		return errors.New("AZURE_CLIENT_SECRET environment variable not found")
+	secretsClient, err := azsecrets.NewClient(kv.vaultBaseURL, chain, &azsecrets.ClientOptions{ClientOptions: clientOpts})
+	if err != nil {
+		return fmt.Errorf("Error creating Key Vault secrets client: %v", err)
 	}
 
-	authorizer, err := auth.NewAuthorizerFromEnvironment()
+	certificatesClient, err := azcertificates.NewClient(kv.vaultBaseURL, chain, &azcertificates.ClientOptions{ClientOptions: clientOpts})
 	if err != nil {
-		return fmt.Errorf("Error occurred while authorizing: %v", err)
+		return fmt.Errorf("Error creating Key Vault certificates client: %v", err)
 	}
 
-	kv.client.Authorizer = authorizer
+	kv.secretsClient = secretsClient
+	kv.certificatesClient = certificatesClient
 	kv.authenticated = true
 
 	return nil
 }
 
-// GetCertificate returns an X509 Certificate from Azure Key Vault Certificate store.
-func (kv *AzureKeyVault) GetCertificate(ctx context.Context, certName string) (*tls.Certificate, error) {
+// workloadIdentityCredential builds a credential that exchanges the AKS Workload Identity
+// federated token for an AAD access token. It returns nil when opts and the environment
+// variables the AKS workload identity webhook injects don't together describe a complete
+// configuration.
+func workloadIdentityCredential(opts WorkloadIdentityOptions, clientOpts azcore.ClientOptions) azcore.TokenCredential {
+	tenantID := opts.TenantID
+	if tenantID == "" {
+		tenantID = os.Getenv("AZURE_TENANT_ID")
+	}
+
+	clientID := opts.ClientID
+	if clientID == "" {
+		clientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+
+	tokenFile := opts.TokenFilePath
+	if tokenFile == "" {
+		tokenFile = os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+	}
+
+	if tenantID == "" || clientID == "" || tokenFile == "" {
+		return nil
+	}
+
+	cache := &federatedTokenCache{path: tokenFile}
+
+	cred, err := azidentity.NewClientAssertionCredential(tenantID, clientID, cache.assertion, &azidentity.ClientAssertionCredentialOptions{ClientOptions: clientOpts})
+	if err != nil {
+		return nil
+	}
+
+	return cred
+}
+
+// managedIdentityCredential builds a credential for a system-assigned identity, or a
+// user-assigned identity when clientID (or AZURE_CLIENT_ID) is set.
+func managedIdentityCredential(clientID string, clientOpts azcore.ClientOptions) azcore.TokenCredential {
+	if clientID == "" {
+		clientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+
+	opts := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: clientOpts}
+	if clientID != "" {
+		opts.ID = azidentity.ClientID(clientID)
+	}
+
+	cred, err := azidentity.NewManagedIdentityCredential(opts)
+	if err != nil {
+		return nil
+	}
+
+	return cred
+}
+
+// environmentClientSecretCredential preserves the pre-azidentity AuthorizeFromEnvironment
+// behavior: a service principal authenticated via AZURE_TENANT_ID, AZURE_CLIENT_ID, and
+// AZURE_CLIENT_SECRET. It returns nil if any of those are unset.
+func environmentClientSecretCredential(clientOpts azcore.ClientOptions) azcore.TokenCredential {
+	tenantID := os.Getenv("AZURE_TENANT_ID")
+	clientID := os.Getenv("AZURE_CLIENT_ID")
+	clientSecret := os.Getenv("AZURE_CLIENT_SECRET")
+
+	if tenantID == "" || clientID == "" || clientSecret == "" {
+		return nil
+	}
+
+	cred, err := azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, &azidentity.ClientSecretCredentialOptions{ClientOptions: clientOpts})
+	if err != nil {
+		return nil
+	}
+
+	return cred
+}
+
+// GetCertificate returns an X509 Certificate from Azure Key Vault Certificate store. If the
+// certificate's PFX contains a chain (intermediate and/or root certs alongside the leaf),
+// every cert in the chain is included in the returned tls.Certificate, leaf first.
+//
+// password is optional and only needed if the certificate's backing PFX is itself
+// password-protected; pass no argument (or an empty string) for the common case of an
+// unprotected PFX.
+func (kv *AzureKeyVault) GetCertificate(ctx context.Context, certName string, password ...string) (*tls.Certificate, error) {
+	certVersion, err := kv.getLatestCertVersion(ctx, certName)
+	if err != nil {
+		return nil, err
+	}
+
+	return kv.GetCertificateByVersion(ctx, certName, certVersion, password...)
+}
+
+// GetCertificateByVersion returns an X509 Certificate from Azure Key Vault Certificate store,
+// pinned to a specific version rather than whatever is currently enabled and most recently
+// updated. Use GetCertificateVersions to discover available version identifiers. Pinning to a
+// version is useful when a deploy needs to be reproducible regardless of certificates rotated
+// into Key Vault afterward. See GetCertificate regarding the optional password argument.
+func (kv *AzureKeyVault) GetCertificateByVersion(ctx context.Context, certName, version string, password ...string) (*tls.Certificate, error) {
+	pfxBytes, err := kv.getCertificatePFX(ctx, certName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return pfxToTLSCertificate(pfxBytes, pfxPassword(password))
+}
+
+// GetCertificateChain returns the parsed, leaf-first X.509 certificate chain for the named
+// Key Vault certificate. See GetCertificate regarding the optional password argument.
+func (kv *AzureKeyVault) GetCertificateChain(ctx context.Context, certName string, password ...string) ([]*x509.Certificate, error) {
+	cert, err := kv.GetCertificate(ctx, certName, password...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCertificateChain(cert)
+}
+
+// parseCertificateChain parses every DER certificate carried by cert into its leaf-first
+// X.509 chain.
+func parseCertificateChain(cert *tls.Certificate) ([]*x509.Certificate, error) {
+	chain := make([]*x509.Certificate, 0, len(cert.Certificate))
+	for _, der := range cert.Certificate {
+		parsed, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing certificate in chain: %v", err)
+		}
+		chain = append(chain, parsed)
+	}
+
+	return chain, nil
+}
+
+// CertificateMetadata summarizes a certificate's validity window and identity fields, parsed
+// from its leaf X.509 certificate. It's useful for callers that want to alert on impending
+// expiry without fetching and parsing the full chain themselves.
+type CertificateMetadata struct {
+	NotBefore time.Time
+	NotAfter  time.Time
+	Issuer    string
+	Subject   string
+	// SANs holds all of the leaf certificate's subject alternative names - DNS names, IP
+	// addresses, email addresses, and URIs - each formatted as a string.
+	SANs []string
+}
+
+// GetCertificateMetadata returns validity and identity information parsed from the named Key
+// Vault certificate's leaf X.509 certificate. See GetCertificate regarding the optional
+// password argument.
+func (kv *AzureKeyVault) GetCertificateMetadata(ctx context.Context, certName string, password ...string) (*CertificateMetadata, error) {
+	cert, err := kv.GetCertificate(ctx, certName, password...)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := parseCertificateChain(cert)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("Certificate has no leaf certificate")
+	}
+
+	leaf := chain[0]
+	return &CertificateMetadata{
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+		Issuer:    leaf.Issuer.String(),
+		Subject:   leaf.Subject.String(),
+		SANs:      subjectAlternativeNames(leaf),
+	}, nil
+}
+
+// subjectAlternativeNames collects every SAN type x509.Certificate exposes - DNS names, IP
+// addresses, email addresses, and URIs - into a single slice of strings.
+func subjectAlternativeNames(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses)+len(cert.EmailAddresses)+len(cert.URIs))
+
+	sans = append(sans, cert.DNSNames...)
+
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+
+	sans = append(sans, cert.EmailAddresses...)
+
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	return sans
+}
+
+// CertVersion describes one version of a Key Vault certificate, as returned by
+// GetCertificateVersions.
+type CertVersion struct {
+	// ID is the version identifier; pass it to GetCertificateByVersion to pin to this version.
+	ID string
+	// Enabled reports whether this version is currently enabled.
+	Enabled bool
+	// Created is when this version was created.
+	Created time.Time
+	// Updated is when this version was last updated.
+	Updated time.Time
+	// Expires is when this version's certificate expires.
+	Expires time.Time
+	// Thumbprint is the X.509 thumbprint of this version's certificate.
+	Thumbprint []byte
+	// Tags holds this version's application-specific metadata.
+	Tags map[string]string
+}
+
+// GetCertificateVersions lists every version of the named Key Vault certificate, most recent
+// first within each page as returned by Key Vault, along with the metadata (enabled state,
+// timestamps, thumbprint, tags) needed to pick one for GetCertificateByVersion.
+func (kv *AzureKeyVault) GetCertificateVersions(ctx context.Context, certName string) ([]CertVersion, error) {
 	if !kv.authenticated {
-		return nil, errors.New("Not Authorized - invoke AuthorizeFromEnvironment() first")
+		return nil, errors.New("Not Authorized - invoke Authorize() first")
 	}
 
 	// make sure a cert name is provided, otherwise we risk returning the wrong certificate
@@ -150,60 +510,127 @@ func (kv *AzureKeyVault) GetCertificate(ctx context.Context, certName string) (*
 		return nil, errors.New("Certificate name not provided")
 	}
 
-	// get version id for current version of certificate
-	certVersion, err := kv.getLatestCertVersion(ctx, certName)
-	if err != nil {
-		return nil, err
+	pager := kv.certificatesClient.NewListCertificatePropertiesVersionsPager(certName, nil)
+
+	var versions []CertVersion
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error while trying to fetch certificate versions from Azure Key Vault: %v", err)
+		}
+
+		for _, item := range page.Value {
+			versions = append(versions, certVersionFromProperties(item))
+		}
+	}
+
+	return versions, nil
+}
+
+// certVersionFromProperties converts a CertificateProperties page item into a CertVersion,
+// dereferencing the optional fields Key Vault may omit.
+func certVersionFromProperties(item *azcertificates.CertificateProperties) CertVersion {
+	v := CertVersion{
+		Thumbprint: item.X509Thumbprint,
+		Tags:       make(map[string]string, len(item.Tags)),
+	}
+
+	if item.ID != nil {
+		v.ID = item.ID.Version()
+	}
+
+	for key, val := range item.Tags {
+		if val != nil {
+			v.Tags[key] = *val
+		}
+	}
+
+	if attrs := item.Attributes; attrs != nil {
+		if attrs.Enabled != nil {
+			v.Enabled = *attrs.Enabled
+		}
+		if attrs.Created != nil {
+			v.Created = *attrs.Created
+		}
+		if attrs.Updated != nil {
+			v.Updated = *attrs.Updated
+		}
+		if attrs.Expires != nil {
+			v.Expires = *attrs.Expires
+		}
+	}
+
+	return v
+}
+
+// getCertificatePFX fetches and base64-decodes the raw PFX backing the named version of a
+// Key Vault certificate.
+func (kv *AzureKeyVault) getCertificatePFX(ctx context.Context, certName, version string) ([]byte, error) {
+	if !kv.authenticated {
+		return nil, errors.New("Not Authorized - invoke Authorize() first")
+	}
+
+	// make sure a cert name is provided, otherwise we risk returning the wrong certificate
+	if strings.Trim(certName, " ") == "" {
+		return nil, errors.New("Certificate name not provided")
 	}
 
 	// Fetch key from secret in Azure Key Vault.
-	secBundle, err := kv.client.GetSecret(ctx, kv.vaultBaseURL, certName, certVersion)
+	secResp, err := kv.secretsClient.GetSecret(ctx, certName, version, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Error fetching secret: %v", err)
 	}
 
 	// Decode string to byte slice
-	pfxBytes, err := base64.StdEncoding.DecodeString(*secBundle.Value)
+	pfxBytes, err := base64.StdEncoding.DecodeString(*secResp.Value)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to decode keyvault.SecretBundle.Value: %v", err)
+		return nil, fmt.Errorf("Unable to decode secret value: %v", err)
 	}
 
+	return pfxBytes, nil
+}
+
+// pfxPassword returns the single optional password argument passed to GetCertificate or
+// GetCertificateChain, or "" if none was given.
+func pfxPassword(password []string) string {
+	if len(password) == 0 {
+		return ""
+	}
+
+	return password[0]
+}
+
+// pfxToTLSCertificate decodes a PKCS#12 blob containing a private key and one or more X.509
+// certificates - a leaf certificate and, optionally, the intermediate/root certs in its chain -
+// into a tls.Certificate. The returned Certificate.Certificate slice preserves chain order:
+// leaf first, followed by any intermediates and the root.
+func pfxToTLSCertificate(pfxBytes []byte, password string) (*tls.Certificate, error) {
 	// Using ToPEM, because some of our PFX files contain multiple certs (cert chain).
 	// Decode throws an error if there are multiple certs.
-	pemBlocks, err := pkcs12.ToPEM(pfxBytes, "")
+	pemBlocks, err := pkcs12.ToPEM(pfxBytes, password)
 	if err != nil {
 		return nil, fmt.Errorf("Error converting PFX contents to PEM blocks: %v", err)
 	}
 
-	// A PFX can contain more than one cert and we need to account for that here.
-	certs := &azureKeyVaultCert{}
-	for i, v := range pemBlocks {
-		if strings.Contains(v.Type, "KEY") == true {
-			var keyPEM bytes.Buffer
-			err = pem.Encode(&keyPEM, pemBlocks[i])
-			if err != nil {
+	var keyPEM bytes.Buffer
+	var certPEM bytes.Buffer
+	for i, block := range pemBlocks {
+		if strings.Contains(block.Type, "KEY") == true {
+			if err := pem.Encode(&keyPEM, pemBlocks[i]); err != nil {
 				return nil, fmt.Errorf("Error encoding key pem block: %v", err)
 			}
-			certs.key = keyPEM.Bytes()
 		}
 
-		if strings.Contains(v.Type, "CERTIFICATE") == true {
-			var certPEM bytes.Buffer
-			err = pem.Encode(&certPEM, pemBlocks[1])
-			if err != nil {
+		if strings.Contains(block.Type, "CERTIFICATE") == true {
+			if err := pem.Encode(&certPEM, pemBlocks[i]); err != nil {
 				return nil, fmt.Errorf("Error encoding certificate pem block: %v", err)
 			}
-
-			if certs.cert == nil {
-				certs.cert = certPEM.Bytes()
-			} else {
-				certs.cert = append(certs.cert, certPEM.Bytes()...)
-			}
 		}
 	}
 
-	// Convert to x509 certificate
-	cert, err := tls.X509KeyPair(certs.cert, certs.key)
+	// Convert to x509 certificate. X509KeyPair records one Certificate entry per CERTIFICATE
+	// block it finds in certPEM, so the full chain survives in cert.Certificate.
+	cert, err := tls.X509KeyPair(certPEM.Bytes(), keyPEM.Bytes())
 	if err != nil {
 		return nil, fmt.Errorf("Error creating X509 Key Pair: %v", err)
 	}
@@ -211,34 +638,26 @@ func (kv *AzureKeyVault) GetCertificate(ctx context.Context, certName string) (*
 	return &cert, nil
 }
 
-// getLatestCertVersion returns the identifier for the most recent version of the certificate.
+// getLatestCertVersion returns the identifier for the most recent enabled version of the
+// certificate.
 func (kv *AzureKeyVault) getLatestCertVersion(ctx context.Context, certName string) (version string, err error) {
-	// List certificate versions
-	list, err := kv.client.GetCertificateVersionsComplete(ctx, kv.vaultBaseURL, certName, nil)
+	versions, err := kv.GetCertificateVersions(ctx, certName)
 	if err != nil {
-		return "", fmt.Errorf("Error while trying to fetch certificate versions from Azure Key Vault: %v", err)
+		return "", err
 	}
 
-	// Iterate through the list and get the last version
+	// Find the most recently updated, enabled version
 	var lastItemDate time.Time
 	var lastItemVersion string
-	for list.NotDone() {
-		// Get element
-		item := list.Value()
-		// Filter only enabled items
-		if *item.Attributes.Enabled {
-			// Get the most recent element
-			updatedTime := time.Time(*item.Attributes.Updated)
-			if lastItemDate.IsZero() || updatedTime.After(lastItemDate) {
-				lastItemDate = updatedTime
-
-				// Get the ID
-				parts := strings.Split(*item.ID, "/")
-				lastItemVersion = parts[len(parts)-1]
-			}
+	for _, v := range versions {
+		if !v.Enabled {
+			continue
+		}
+
+		if lastItemDate.IsZero() || v.Updated.After(lastItemDate) {
+			lastItemDate = v.Updated
+			lastItemVersion = v.ID
 		}
-		// Iterate to next
-		list.Next()
 	}
 
 	return lastItemVersion, nil
@@ -6,11 +6,94 @@ package kvcert
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
+	"math/big"
+	"net"
+	"net/url"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azcertificates"
+)
+
+const (
+	multiCertPFXFixture        = "testdata/multi-cert.pfx"
+	modernPasswordPFXFixture   = "testdata/modern-password.pfx"
+	modernNoPasswordPFXFixture = "testdata/modern-nopassword.pfx"
 )
 
+func TestNewWithCloud(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	custom := CloudCustom("https://login.example.com/", "vault.example.com", "https://vault.example.com")
+
+	cases := []struct {
+		name                string
+		cloud               Cloud
+		wantVaultBaseURL    string
+		wantAuthorityHost   string
+		wantServiceAudience string
+		wantServiceEndpoint string
+	}{
+		{
+			name:                "CloudUSGovernment",
+			cloud:               CloudUSGovernment,
+			wantVaultBaseURL:    "https://my-vault.vault.usgovcloudapi.net",
+			wantAuthorityHost:   "https://login.microsoftonline.us/",
+			wantServiceAudience: "https://vault.usgovcloudapi.net",
+			wantServiceEndpoint: "https://vault.usgovcloudapi.net",
+		},
+		{
+			name:                "CloudChina",
+			cloud:               CloudChina,
+			wantVaultBaseURL:    "https://my-vault.vault.azure.cn",
+			wantAuthorityHost:   "https://login.chinacloudapi.cn/",
+			wantServiceAudience: "https://vault.azure.cn",
+			wantServiceEndpoint: "https://vault.azure.cn",
+		},
+		{
+			name:                "CloudCustom",
+			cloud:               custom,
+			wantVaultBaseURL:    "https://my-vault.vault.example.com",
+			wantAuthorityHost:   "https://login.example.com/",
+			wantServiceAudience: "https://vault.example.com",
+			wantServiceEndpoint: "https://vault.example.com",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			akv := NewWithCloud("my-vault", c.cloud)
+
+			if akv.vaultBaseURL != c.wantVaultBaseURL {
+				t.Fatalf("Expected vaultBaseURL %q, got %q\n", c.wantVaultBaseURL, akv.vaultBaseURL)
+			}
+
+			cfg := akv.cloud.aadCloudConfig()
+			if cfg.ActiveDirectoryAuthorityHost != c.wantAuthorityHost {
+				t.Fatalf("Expected ActiveDirectoryAuthorityHost %q, got %q\n", c.wantAuthorityHost, cfg.ActiveDirectoryAuthorityHost)
+			}
+
+			svc, ok := cfg.Services[keyVaultService]
+			if !ok {
+				t.Fatal("Expected Services to contain an entry for keyVaultService")
+			}
+			if svc.Audience != c.wantServiceAudience {
+				t.Fatalf("Expected Services[keyVaultService].Audience %q, got %q\n", c.wantServiceAudience, svc.Audience)
+			}
+			if svc.Endpoint != c.wantServiceEndpoint {
+				t.Fatalf("Expected Services[keyVaultService].Endpoint %q, got %q\n", c.wantServiceEndpoint, svc.Endpoint)
+			}
+		})
+	}
+}
+
 func TestNewAzureKeyVault(t *testing.T) {
 	fmt.Printf("Running %s\n", t.Name())
 
@@ -21,7 +104,7 @@ func TestNewAzureKeyVault(t *testing.T) {
 	}
 }
 
-func TestAuthorizeFromEnvironment(t *testing.T) {
+func TestAuthorize(t *testing.T) {
 	fmt.Printf("Running %s\n", t.Name())
 	fmt.Println("  Verify the following environment variables are set...")
 	fmt.Printf("  KEY_VAULT_NAME: %s\n", os.Getenv("KEY_VAULT_NAME"))
@@ -30,7 +113,7 @@ func TestAuthorizeFromEnvironment(t *testing.T) {
 	fmt.Printf("  AZURE_CLIENT_SECRET: %s\n", os.Getenv("AZURE_CLIENT_SECRET"))
 
 	akv := New(os.Getenv("KEY_VAULT_NAME"))
-	err := akv.AuthorizeFromEnvironment()
+	err := akv.Authorize(AuthOptions{})
 	if err != nil {
 		t.Fatalf("Error attempting to authorize azure key vault %v\n", err)
 	}
@@ -40,7 +123,7 @@ func TestGetCertificate(t *testing.T) {
 	fmt.Printf("Running %s\n", t.Name())
 	akv := New(os.Getenv("KEY_VAULT_NAME"))
 
-	err := akv.AuthorizeFromEnvironment()
+	err := akv.Authorize(AuthOptions{})
 	if err != nil {
 		t.Fatalf("Error attempting to authorize azure key vault: %v\n", err)
 	}
@@ -51,12 +134,215 @@ func TestGetCertificate(t *testing.T) {
 		t.Fatalf("Error attempting to fetch cert: %v\n", err)
 	}
 	if cert == nil {
-		t.Fatal("Expected AzureKeyVaultCert struct, received nil")
+		t.Fatal("Expected tls.Certificate struct, received nil")
+	}
+	if cert.PrivateKey == nil {
+		t.Fatal("Expected cert private key, received nil")
+	}
+	if len(cert.Certificate) == 0 {
+		t.Fatal("Expected at least one certificate in the chain, received none")
+	}
+}
+
+func TestGetCertificateVersions(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+	akv := New(os.Getenv("KEY_VAULT_NAME"))
+
+	err := akv.Authorize(AuthOptions{})
+	if err != nil {
+		t.Fatalf("Error attempting to authorize azure key vault: %v\n", err)
+	}
+	ctx := context.Background()
+
+	versions, err := akv.GetCertificateVersions(ctx, os.Getenv("CERT_NAME"))
+	if err != nil {
+		t.Fatalf("Error attempting to fetch cert versions: %v\n", err)
+	}
+	if len(versions) == 0 {
+		t.Fatal("Expected at least one certificate version, received none")
+	}
+
+	cert, err := akv.GetCertificateByVersion(ctx, os.Getenv("CERT_NAME"), versions[0].ID)
+	if err != nil {
+		t.Fatalf("Error attempting to fetch cert by version: %v\n", err)
+	}
+	if cert == nil {
+		t.Fatal("Expected tls.Certificate struct, received nil")
+	}
+}
+
+func TestGetCertificateMetadata(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+	akv := New(os.Getenv("KEY_VAULT_NAME"))
+
+	err := akv.Authorize(AuthOptions{})
+	if err != nil {
+		t.Fatalf("Error attempting to authorize azure key vault: %v\n", err)
+	}
+	ctx := context.Background()
+
+	meta, err := akv.GetCertificateMetadata(ctx, os.Getenv("CERT_NAME"))
+	if err != nil {
+		t.Fatalf("Error attempting to fetch cert metadata: %v\n", err)
+	}
+	if meta.NotAfter.Before(meta.NotBefore) {
+		t.Fatal("Expected NotAfter to be after NotBefore")
+	}
+	if meta.Subject == "" {
+		t.Fatal("Expected non-empty Subject")
+	}
+}
+
+func TestSubjectAlternativeNames(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %v\n", err)
 	}
-	if cert.Key == nil {
-		t.Fatal("Expected cert key as byte slice, received nil")
+
+	emailURI, err := url.Parse("spiffe://example.com/my-service")
+	if err != nil {
+		t.Fatalf("Error parsing URI: %v\n", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "multi-san.example.com"},
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(time.Hour),
+		DNSNames:       []string{"multi-san.example.com", "alt.example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		EmailAddresses: []string{"admin@example.com"},
+		URIs:           []*url.URL{emailURI},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %v\n", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %v\n", err)
+	}
+
+	sans := subjectAlternativeNames(cert)
+
+	want := []string{"multi-san.example.com", "alt.example.com", "10.0.0.1", "admin@example.com", "spiffe://example.com/my-service"}
+	if len(sans) != len(want) {
+		t.Fatalf("Expected %d SANs, got %d: %v\n", len(want), len(sans), sans)
+	}
+	for i, w := range want {
+		if sans[i] != w {
+			t.Fatalf("Expected SAN %d to be %q, got %q\n", i, w, sans[i])
+		}
+	}
+}
+
+func TestPfxToTLSCertificateMultiCert(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	pfxBytes, err := os.ReadFile(multiCertPFXFixture)
+	if err != nil {
+		t.Fatalf("Error reading %s: %v\n", multiCertPFXFixture, err)
+	}
+
+	cert, err := pfxToTLSCertificate(pfxBytes, "")
+	if err != nil {
+		t.Fatalf("Error converting PFX to tls.Certificate: %v\n", err)
+	}
+
+	if len(cert.Certificate) != 2 {
+		t.Fatalf("Expected 2 certs in chain (leaf + root), got %d\n", len(cert.Certificate))
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Error parsing leaf certificate: %v\n", err)
+	}
+	if leaf.IsCA {
+		t.Fatal("Expected first cert in chain to be the leaf, got a CA cert")
+	}
+
+	root, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		t.Fatalf("Error parsing root certificate: %v\n", err)
+	}
+	if !root.IsCA {
+		t.Fatal("Expected second cert in chain to be the root CA")
+	}
+}
+
+func TestCertVersionFromProperties(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	enabled := true
+	updated := time.Now()
+	tagVal := "prod"
+	id := azcertificates.ID("https://example.vault.azure.net/certificates/my-cert/abc123")
+
+	item := &azcertificates.CertificateProperties{
+		ID:             &id,
+		X509Thumbprint: []byte{0x01, 0x02},
+		Tags:           map[string]*string{"env": &tagVal, "empty": nil},
+		Attributes: &azcertificates.CertificateAttributes{
+			Enabled: &enabled,
+			Updated: &updated,
+		},
 	}
-	if cert.Cert == nil {
-		t.Fatal("Expected certificate as byte slice, received nil")
+
+	v := certVersionFromProperties(item)
+
+	if v.ID != "abc123" {
+		t.Fatalf("Expected ID %q, got %q\n", "abc123", v.ID)
+	}
+	if !v.Enabled {
+		t.Fatal("Expected Enabled to be true")
+	}
+	if !v.Updated.Equal(updated) {
+		t.Fatalf("Expected Updated %v, got %v\n", updated, v.Updated)
+	}
+	if v.Created.IsZero() != true {
+		t.Fatal("Expected Created to be zero value since Attributes.Created was nil")
+	}
+	if v.Tags["env"] != "prod" {
+		t.Fatalf("Expected Tags[\"env\"] to be %q, got %q\n", "prod", v.Tags["env"])
+	}
+	if _, ok := v.Tags["empty"]; ok {
+		t.Fatal("Expected nil tag values to be omitted from Tags")
+	}
+}
+
+func TestPfxToTLSCertificateModernPBE(t *testing.T) {
+	fmt.Printf("Running %s\n", t.Name())
+
+	cases := []struct {
+		name     string
+		fixture  string
+		password string
+	}{
+		{"with password", modernPasswordPFXFixture, "hunter2"},
+		{"without password", modernNoPasswordPFXFixture, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pfxBytes, err := os.ReadFile(c.fixture)
+			if err != nil {
+				t.Fatalf("Error reading %s: %v\n", c.fixture, err)
+			}
+
+			cert, err := pfxToTLSCertificate(pfxBytes, c.password)
+			if err != nil {
+				t.Fatalf("Error converting OpenSSL 3 PBES2/AES-256 PFX to tls.Certificate: %v\n", err)
+			}
+			if cert.PrivateKey == nil {
+				t.Fatal("Expected cert private key, received nil")
+			}
+			if len(cert.Certificate) == 0 {
+				t.Fatal("Expected at least one certificate in the chain, received none")
+			}
+		})
 	}
 }